@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ProberConfig describes a single vantage point that probes targets using
+// its own source address, and optionally its own routing mark or network
+// namespace.
+type ProberConfig struct {
+	Name  string `yaml:"name"`
+	Bind  string `yaml:"bind,omitempty"`
+	Mark  int    `yaml:"mark,omitempty"`
+	Netns string `yaml:"netns,omitempty"`
+}
+
+// TargetGroup is a named set of targets. When Probers is non-empty, only
+// the listed probers probe this group; otherwise every configured prober
+// does.
+type TargetGroup struct {
+	Name    string   `yaml:"name"`
+	Hosts   []Target `yaml:"hosts"`
+	Probers []string `yaml:"probers,omitempty"`
+}
+
+// Config is the top-level shape of the YAML file passed on the command
+// line, replacing the old newline-separated target file.
+type Config struct {
+	Probers []ProberConfig `yaml:"probers"`
+	Targets []TargetGroup  `yaml:"targets"`
+}
+
+func loadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// targetsFor returns the targets that the named prober should probe,
+// across all target groups.
+func (c *Config) targetsFor(prober string) []Target {
+	var targets []Target
+	for _, g := range c.Targets {
+		if len(g.Probers) > 0 && !containsString(g.Probers, prober) {
+			continue
+		}
+		targets = append(targets, g.Hosts...)
+	}
+	return targets
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsProber resolves a target's host against an optional nameserver and
+// measures resolution latency, optionally checking the answer against an
+// expected value.
+type dnsProber struct{}
+
+func (dnsProber) probe(targets []Target, timeout time.Duration, opts probeOpts, record func(t Target, ok bool, rtt time.Duration)) error {
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		t := t
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, rtt := probeDNS(t, timeout, opts)
+			record(t, ok, rtt)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func probeDNS(t Target, timeout time.Duration, opts probeOpts) (bool, time.Duration) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			// Entered here, at the actual dial, rather than around
+			// the lookup call: Go's resolver may run this Dial
+			// callback from a goroutine of its own choosing, so
+			// locking has to happen on whichever goroutine ends up
+			// calling it, right before the socket is created.
+			d := net.Dialer{Timeout: timeout, Control: controlMark(opts.Mark)}
+			if opts.LocalAddr != "" {
+				// The resolver retries over TCP when a UDP answer is
+				// truncated, so the local address type must match
+				// whichever network it's dialing.
+				ip := net.ParseIP(opts.LocalAddr)
+				if network == "tcp" {
+					d.LocalAddr = &net.TCPAddr{IP: ip}
+				} else {
+					d.LocalAddr = &net.UDPAddr{IP: ip}
+				}
+			}
+			ns := address
+			if t.Nameserver != "" {
+				ns = t.Nameserver
+				if _, _, err := net.SplitHostPort(ns); err != nil {
+					ns = net.JoinHostPort(ns, "53")
+				}
+			}
+			var conn net.Conn
+			err := withNetns(opts.Netns, func() error {
+				c, err := d.DialContext(ctx, network, ns)
+				conn = c
+				return err
+			})
+			return conn, err
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	var ok bool
+	switch t.Record {
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, t.Host)
+		ok = err == nil && (t.Answer == "" || cname == t.Answer)
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, t.Host)
+		ok = err == nil && (t.Answer == "" || containsString(txts, t.Answer))
+	default:
+		addrs, err := resolver.LookupHost(ctx, t.Host)
+		ok = err == nil && (t.Answer == "" || containsString(addrs, t.Answer))
+	}
+	return ok, time.Since(start)
+}
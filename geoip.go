@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// geoInfo is the subset of a GeoLite2 City record pingmeter attaches to
+// target labels.
+type geoInfo struct {
+	Country   string
+	Continent string
+	City      string
+	Lat       float64
+	Lon       float64
+}
+
+// geoipCache resolves a host to its GeoLite2 City info, caching results
+// per-host for ttl so both the DNS resolution of hostname targets and the
+// GeoLite2 DB read stay out of the hot probe path.
+type geoipCache struct {
+	db  *geoip2.Reader
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]geoCacheEntry
+}
+
+type geoCacheEntry struct {
+	info    geoInfo
+	found   bool
+	expires time.Time
+}
+
+func openGeoIP(path string, ttl time.Duration) (*geoipCache, error) {
+	if path == "" {
+		return nil, nil
+	}
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &geoipCache{db: db, ttl: ttl, entries: make(map[string]geoCacheEntry)}, nil
+}
+
+// lookup resolves host's GeoLite2 City info, returning false if geoip is
+// disabled or the lookup fails. Both positive and negative results are
+// cached for ttl, so a host that can't be resolved or placed doesn't pay
+// for a fresh net.ResolveIPAddr on every probe round.
+func (g *geoipCache) lookup(host string) (geoInfo, bool) {
+	if g == nil {
+		return geoInfo{}, false
+	}
+
+	g.mu.Lock()
+	e, ok := g.entries[host]
+	g.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.info, e.found
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ra, err := net.ResolveIPAddr("ip", host)
+		if err != nil {
+			g.cache(host, geoCacheEntry{expires: time.Now().Add(g.ttl)})
+			return geoInfo{}, false
+		}
+		ip = ra.IP
+	}
+
+	rec, err := g.db.City(ip)
+	if err != nil {
+		g.cache(host, geoCacheEntry{expires: time.Now().Add(g.ttl)})
+		return geoInfo{}, false
+	}
+	info := geoInfo{
+		Country:   rec.Country.IsoCode,
+		Continent: rec.Continent.Code,
+		City:      rec.City.Names["en"],
+		Lat:       rec.Location.Latitude,
+		Lon:       rec.Location.Longitude,
+	}
+	g.cache(host, geoCacheEntry{info: info, found: true, expires: time.Now().Add(g.ttl)})
+	return info, true
+}
+
+func (g *geoipCache) cache(host string, e geoCacheEntry) {
+	g.mu.Lock()
+	g.entries[host] = e
+	g.mu.Unlock()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// httpProber measures TTFB against a target's URL (or, if unset, plain
+// "http://host") and optionally checks the response body against a
+// regexp.
+type httpProber struct{}
+
+func (httpProber) probe(targets []Target, timeout time.Duration, opts probeOpts, record func(t Target, ok bool, rtt time.Duration)) error {
+	dialer := &net.Dialer{Timeout: timeout}
+	dialer.Control = controlMark(opts.Mark)
+	if opts.LocalAddr != "" {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(opts.LocalAddr)}
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// Entering netns here, right where the connection's
+			// socket is created, keeps it correct even though the
+			// transport may invoke this from its own goroutine.
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var conn net.Conn
+				err := withNetns(opts.Netns, func() error {
+					c, err := dialer.DialContext(ctx, network, addr)
+					conn = c
+					return err
+				})
+				return conn, err
+			},
+		},
+	}
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		t := t
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, rtt := probeHTTP(client, t, opts.Source)
+			record(t, ok, rtt)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func probeHTTP(client *http.Client, t Target, sourceName string) (bool, time.Duration) {
+	url := t.URL
+	if url == "" {
+		url = "http://" + t.Host
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return false, 0
+	}
+
+	var start, ttfb time.Time
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { ttfb = time.Now() },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(context.Background(), trace))
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, 0
+	}
+	defer resp.Body.Close()
+
+	pingMetrics.httpStatusCode.WithLabelValues(t.Host, sourceName).Set(float64(resp.StatusCode))
+
+	ok := resp.StatusCode < 400
+	if ok && t.Regex != "" {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return false, 0
+		}
+		matched, err := regexp.Match(t.Regex, body)
+		if err != nil || !matched {
+			ok = false
+		}
+	}
+	return ok, ttfb.Sub(start)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/tatsushid/go-fastping"
+)
+
+// icmpProbe pings targets in a single batched fastping round, which is
+// why it isn't just another Prober: unlike the other modules it checks
+// every target in one socket round-trip instead of one dial per target.
+// p.Run() creates its socket synchronously before spawning its receive
+// loop, so entering netns tightly around that call is enough to make the
+// socket (and everything read from it afterward) belong to that
+// namespace, even though the receive loop itself runs on another thread.
+func icmpProbe(sourceName string, targets []Target, timeout time.Duration, localAddr, netns string) error {
+	byAddr := make(map[string]Target)
+	rtts := make(map[string]time.Duration)
+
+	p := fastping.NewPinger()
+	p.MaxRTT = timeout
+	if localAddr != "" {
+		if err := p.Source(localAddr); err != nil {
+			return err
+		}
+	}
+	p.OnRecv = func(addr *net.IPAddr, rtt time.Duration) {
+		rtts[addr.String()] = rtt
+	}
+
+	for _, t := range targets {
+		ra, err := net.ResolveIPAddr("ip:icmp", t.Host)
+		if err != nil {
+			pingMetrics.resolveError(sourceName, t.Host)
+			continue
+		}
+		byAddr[ra.String()] = t
+		p.AddIPAddr(ra)
+	}
+	defer func() {
+		for addr, t := range byAddr {
+			rtt, ok := rtts[addr]
+			pingMetrics.update(sourceName, t.Host, "icmp", ok, rtt)
+		}
+	}()
+	return withNetns(netns, p.Run)
+}
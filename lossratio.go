@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lossWindow is a fixed-size ring buffer of recent check outcomes, used
+// to compute pingmeter_loss_ratio over a bounded recent window instead of
+// the check's entire lifetime, so the gauge actually moves when a host
+// starts (or stops) failing.
+type lossWindow struct {
+	mu     sync.Mutex
+	hits   []bool
+	cursor int
+	filled int
+}
+
+func newLossWindow(size int) *lossWindow {
+	return &lossWindow{hits: make([]bool, size)}
+}
+
+// record appends ok and returns the loss ratio (share of failures) over
+// the window collected so far.
+func (w *lossWindow) record(ok bool) float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.hits[w.cursor] = ok
+	w.cursor = (w.cursor + 1) % len(w.hits)
+	if w.filled < len(w.hits) {
+		w.filled++
+	}
+
+	failures := 0
+	for i := 0; i < w.filled; i++ {
+		if !w.hits[i] {
+			failures++
+		}
+	}
+	return float64(failures) / float64(w.filled)
+}
+
+// lossWindows hands out a lossWindow per series key, creating it on first
+// use.
+type lossWindows struct {
+	mu   sync.Mutex
+	size int
+	m    map[string]*lossWindow
+}
+
+func newLossWindows(size int) *lossWindows {
+	return &lossWindows{size: size, m: make(map[string]*lossWindow)}
+}
+
+func (lw *lossWindows) get(key string) *lossWindow {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	w, ok := lw.m[key]
+	if !ok {
+		w = newLossWindow(lw.size)
+		lw.m[key] = w
+	}
+	return w
+}
+
+// deleteHost drops every window belonging to host, so a removed target
+// doesn't hold its window in memory forever.
+func (lw *lossWindows) deleteHost(host string) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	prefix := host + "|"
+	for key := range lw.m {
+		if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+			delete(lw.m, key)
+		}
+	}
+}
+
+func lossWindowKey(l prometheus.Labels) string {
+	return l["host"] + "|" + l["source"] + "|" + l["module"] + "|" + l["country"] + "|" + l["continent"] + "|" + l["city"]
+}
@@ -1,164 +1,125 @@
 package main
 
 import (
-	"bytes"
 	"flag"
-	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/tatsushid/go-fastping"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
-	pingInterval = flag.Duration("interval", 10*time.Second, "Ping interval")
-	pingTimeout  = flag.Duration("timeout", 5*time.Second, "Ping timeout")
-	listen       = flag.String("listen", ":9010", "Listen address for prometheus")
+	pingInterval   = flag.Duration("interval", 10*time.Second, "Ping interval")
+	pingTimeout    = flag.Duration("timeout", 5*time.Second, "Ping timeout")
+	listen         = flag.String("listen", ":9010", "Listen address for prometheus")
+	buckets        = flag.String("buckets", "1,2,5,10,20,50,100,200,500,1000", "Comma separated RTT histogram buckets in milliseconds")
+	useSummary     = flag.Bool("summary", false, "Use a Summary instead of a Histogram for pingmeter_rtt_ms")
+	lossWindowSize = flag.Int("loss-window", 100, "Number of recent checks per host used to compute pingmeter_loss_ratio")
+	geoipPath      = flag.String("geoip", "", "Path to a MaxMind GeoLite2 City database used to label targets")
+	geoipTTL       = flag.Duration("geoip-ttl", time.Hour, "How long to cache GeoIP lookups for a given target host")
 
 	pingMetrics *metrics
-)
-
-type metrics struct {
-	ok    *prometheus.CounterVec
-	ng    *prometheus.CounterVec
-	total *prometheus.CounterVec
-	rtt   *prometheus.GaugeVec
-}
-
-func newMetrics() *metrics {
-	m := &metrics{
-		total: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "pingmeter_count_total",
-				Help: "Number of checks",
-			},
-			[]string{"host"},
-		),
-		ok: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "pingmeter_count_ok",
-				Help: "Number of successes",
-			},
-			[]string{"host"},
-		),
-		ng: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "pingmeter_count_ng",
-				Help: "Number of failures",
-			},
-			[]string{"host"},
-		),
-		rtt: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "pingmeter_rtt_ms",
-				Help: "RTT to each host",
-			},
-			[]string{"host"},
-		),
-	}
-	prometheus.MustRegister(m.total)
-	prometheus.MustRegister(m.ok)
-	prometheus.MustRegister(m.ng)
-	prometheus.MustRegister(m.rtt)
-	return m
-}
-
-func (m *metrics) update(host string, ok bool, rtt time.Duration) {
-	m.total.WithLabelValues(host).Inc()
-	if ok {
-		m.ok.WithLabelValues(host).Inc()
-		m.rtt.WithLabelValues(host).Set(rtt.Seconds() * 1000)
-	} else {
-		m.ng.WithLabelValues(host).Inc()
-		m.rtt.WithLabelValues(host).Set(0)
-	}
-}
+	geo         *geoipCache
 
-type result struct {
-	host string
-	ok   bool
-	rtt  time.Duration
-}
+	version   = "dev"
+	commit    = "none"
+	goversion = runtime.Version()
+)
 
-type targetList struct {
-	items []string
-	path  string
-	mtime time.Time
-}
+func pingLoop(path string) {
+	var probers []*prober
+	var cfg *Config
+	var mtime time.Time
+	var knownHosts map[string]bool
+
+	reload := func(force bool) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			log.Print(err)
+			pingMetrics.reloaded(false)
+			return
+		}
+		if !force && !fi.ModTime().After(mtime) {
+			return
+		}
+		mtime = fi.ModTime()
 
-func (t *targetList) read() {
-	fi, err := os.Stat(t.path)
-	if err != nil {
-		log.Print(err)
-		return
-	}
-	mtime := fi.ModTime()
-	if !mtime.After(t.mtime) {
-		return
-	}
-	t.mtime = mtime
+		c, err := loadConfig(path)
+		if err != nil {
+			log.Print(err)
+			pingMetrics.reloaded(false)
+			return
+		}
 
-	b, err := ioutil.ReadFile(t.path)
-	if err != nil {
-		log.Print(err)
-		return
-	}
-	t.items = t.items[:0]
-	for _, item := range bytes.Split(b, []byte{'\n'}) {
-		if len(item) > 0 {
-			t.items = append(t.items, string(item))
+		newHosts := make(map[string]bool)
+		for _, g := range c.Targets {
+			for _, t := range g.Hosts {
+				newHosts[t.Host] = true
+			}
 		}
-	}
-	log.Print("target list updated")
-}
+		for host := range knownHosts {
+			if !newHosts[host] {
+				pingMetrics.deleteHost(host)
+			}
+		}
+		knownHosts = newHosts
 
-func pingLoop(path string) {
-	t := &targetList{path: path}
-	t.read()
-	ping(t.items)
-	for _ = range time.Tick(*pingInterval) {
-		t.read()
-		ping(t.items)
+		cfg = c
+		probers = probers[:0]
+		for _, pc := range cfg.Probers {
+			probers = append(probers, newProber(pc))
+		}
+		pingMetrics.targetListReloads.Inc()
+		pingMetrics.targetListMtime.Set(float64(mtime.Unix()))
+		pingMetrics.reloaded(true)
+		log.Print("config reloaded")
 	}
-}
 
-func ping(hosts []string) error {
-	results := make(map[string]*result)
-	p := fastping.NewPinger()
-	p.MaxRTT = *pingTimeout
-	p.OnRecv = func(addr *net.IPAddr, rtt time.Duration) {
-		if result, ok := results[addr.String()]; ok {
-			result.ok = true
-			result.rtt = rtt
+	round := func() {
+		for _, pr := range probers {
+			if err := pr.ping(cfg.targetsFor(pr.name)); err != nil {
+				log.Printf("prober %s: %s", pr.name, err)
+			}
 		}
 	}
 
-	for _, host := range hosts {
-		ra, err := net.ResolveIPAddr("ip:icmp", host)
-		results[ra.String()] = &result{host: host}
-		if err == nil {
-			p.AddIPAddr(ra)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	reload(true)
+	round()
+
+	ticker := time.NewTicker(*pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			reload(false)
+			round()
+		case <-hup:
+			log.Print("received SIGHUP, forcing config reload")
+			reload(true)
+			round()
 		}
 	}
-	defer func() {
-		for _, r := range results {
-			pingMetrics.update(r.host, r.ok, r.rtt)
-		}
-	}()
-	return p.Run()
 }
 
 func main() {
 	flag.Parse()
-	go pingLoop(os.Args[len(os.Args)-1])
-	http.Handle("/metrics", prometheus.Handler())
-	http.ListenAndServe(*listen, nil)
-}
 
-func init() {
+	g, err := openGeoIP(*geoipPath, *geoipTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	geo = g
 	pingMetrics = newMetrics()
+
+	go pingLoop(os.Args[len(os.Args)-1])
+	http.Handle("/metrics", promhttp.Handler())
+	http.ListenAndServe(*listen, nil)
 }
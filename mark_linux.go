@@ -0,0 +1,27 @@
+// +build linux
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// controlMark returns a net.Dialer Control function that sets SO_MARK on
+// the dial's socket, or nil when mark is zero so the Dialer skips the
+// Control hook entirely.
+func controlMark(mark int) func(network, address string, c syscall.RawConn) error {
+	if mark == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		if err := c.Control(func(fd uintptr) {
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_MARK, mark)
+		}); err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
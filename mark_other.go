@@ -0,0 +1,20 @@
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// controlMark returns a net.Dialer Control function reporting an error,
+// since SO_MARK is Linux-specific; nil when mark is zero so unconfigured
+// dialers are unaffected on any platform.
+func controlMark(mark int) func(network, address string, c syscall.RawConn) error {
+	if mark == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		return fmt.Errorf("socket marks are only supported on linux")
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metrics struct {
+	ok          *prometheus.CounterVec
+	ng          *prometheus.CounterVec
+	total       *prometheus.CounterVec
+	rtt         *prometheus.HistogramVec
+	rttSum      *prometheus.SummaryVec
+	lossRatio   *prometheus.GaugeVec
+	lossWindows *lossWindows
+
+	scrapeDuration     prometheus.Histogram
+	resolveErrors      *prometheus.CounterVec
+	targetListReloads  prometheus.Counter
+	targetListMtime    prometheus.Gauge
+	buildInfo          *prometheus.GaugeVec
+	httpStatusCode     *prometheus.GaugeVec
+	targetLocation     *prometheus.GaugeVec
+	reloadSuccessful   prometheus.Gauge
+	reloadSuccessStamp prometheus.Gauge
+}
+
+func parseBuckets(s string) []float64 {
+	parts := strings.Split(s, ",")
+	b := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			log.Fatalf("invalid bucket %q: %s", p, err)
+		}
+		b = append(b, v)
+	}
+	return b
+}
+
+func newMetrics() *metrics {
+	labels := []string{"host", "source", "module", "country", "continent", "city"}
+	m := &metrics{
+		total: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pingmeter_count_total",
+				Help: "Number of checks",
+			},
+			labels,
+		),
+		ok: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pingmeter_count_ok",
+				Help: "Number of successes",
+			},
+			labels,
+		),
+		ng: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pingmeter_count_ng",
+				Help: "Number of failures",
+			},
+			labels,
+		),
+		lossRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "pingmeter_loss_ratio",
+				Help: "Ratio of failed checks to total checks for each host, over the last -loss-window checks",
+			},
+			labels,
+		),
+		lossWindows: newLossWindows(*lossWindowSize),
+		scrapeDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "pingmeter_scrape_duration_seconds",
+				Help:    "Time it took to complete a single ping() round",
+				Buckets: prometheus.DefBuckets,
+			},
+		),
+		resolveErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pingmeter_resolve_errors_total",
+				// Labeled by source as well as host, intentionally:
+				// once a config can define multiple probers the same
+				// host can be resolved independently from each one.
+				Help: "Number of net.ResolveIPAddr failures for each host",
+			},
+			[]string{"host", "source"},
+		),
+		targetListReloads: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "pingmeter_target_list_reloads_total",
+				Help: "Number of times the target list was reloaded",
+			},
+		),
+		targetListMtime: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "pingmeter_target_list_mtime_seconds",
+				Help: "Modification time of the target list, in seconds since the epoch",
+			},
+		),
+		buildInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "pingmeter_build_info",
+				Help: "Build information about pingmeter",
+			},
+			[]string{"version", "commit", "goversion"},
+		),
+		httpStatusCode: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "pingmeter_http_status_code",
+				Help: "Status code of the last HTTP probe for each host",
+			},
+			[]string{"host", "source"},
+		),
+		targetLocation: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "pingmeter_target_location",
+				Help: "Info metric carrying a target's GeoIP coordinates as labels",
+			},
+			[]string{"host", "lat", "lon"},
+		),
+		reloadSuccessful: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "pingmeter_config_last_reload_successful",
+				Help: "Whether the last config reload attempt succeeded",
+			},
+		),
+		reloadSuccessStamp: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "pingmeter_config_last_reload_success_timestamp_seconds",
+				Help: "Unix timestamp of the last successful config reload",
+			},
+		),
+	}
+	if *useSummary {
+		m.rttSum = prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Name:       "pingmeter_rtt_ms",
+				Help:       "RTT to each host",
+				Objectives: map[float64]float64{0.5: 0.05, 0.95: 0.01, 0.99: 0.001},
+			},
+			labels,
+		)
+		prometheus.MustRegister(m.rttSum)
+	} else {
+		m.rtt = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "pingmeter_rtt_ms",
+				Help:    "RTT to each host",
+				Buckets: parseBuckets(*buckets),
+			},
+			labels,
+		)
+		prometheus.MustRegister(m.rtt)
+	}
+	prometheus.MustRegister(m.total)
+	prometheus.MustRegister(m.ok)
+	prometheus.MustRegister(m.ng)
+	prometheus.MustRegister(m.lossRatio)
+	prometheus.MustRegister(m.scrapeDuration)
+	prometheus.MustRegister(m.resolveErrors)
+	prometheus.MustRegister(m.targetListReloads)
+	prometheus.MustRegister(m.targetListMtime)
+	prometheus.MustRegister(m.buildInfo)
+	prometheus.MustRegister(m.httpStatusCode)
+	prometheus.MustRegister(m.targetLocation)
+	prometheus.MustRegister(m.reloadSuccessful)
+	prometheus.MustRegister(m.reloadSuccessStamp)
+	m.buildInfo.WithLabelValues(version, commit, goversion).Set(1)
+	return m
+}
+
+// deleteHost drops every per-host series across all metrics, used when a
+// config reload removes a target so its last-known value doesn't linger
+// in the registry forever.
+func (m *metrics) deleteHost(host string) {
+	l := prometheus.Labels{"host": host}
+	m.total.DeletePartialMatch(l)
+	m.ok.DeletePartialMatch(l)
+	m.ng.DeletePartialMatch(l)
+	if m.rttSum != nil {
+		m.rttSum.DeletePartialMatch(l)
+	} else {
+		m.rtt.DeletePartialMatch(l)
+	}
+	m.lossRatio.DeletePartialMatch(l)
+	m.lossWindows.deleteHost(host)
+	m.resolveErrors.DeletePartialMatch(l)
+	m.httpStatusCode.DeletePartialMatch(l)
+	m.targetLocation.DeletePartialMatch(l)
+}
+
+func (m *metrics) reloaded(ok bool) {
+	if ok {
+		m.reloadSuccessful.Set(1)
+		m.reloadSuccessStamp.SetToCurrentTime()
+	} else {
+		m.reloadSuccessful.Set(0)
+	}
+}
+
+func (m *metrics) update(source, host, module string, ok bool, rtt time.Duration) {
+	info, hasInfo := geo.lookup(host)
+	l := geoLabels(host, source, module, info, hasInfo)
+	m.total.With(l).Inc()
+	if ok {
+		m.ok.With(l).Inc()
+		if m.rttSum != nil {
+			m.rttSum.With(l).Observe(rtt.Seconds() * 1000)
+		} else {
+			m.rtt.With(l).Observe(rtt.Seconds() * 1000)
+		}
+	} else {
+		m.ng.With(l).Inc()
+	}
+	m.lossRatio.With(l).Set(m.lossWindows.get(lossWindowKey(l)).record(ok))
+
+	if hasInfo {
+		lat := strconv.FormatFloat(info.Lat, 'f', 6, 64)
+		lon := strconv.FormatFloat(info.Lon, 'f', 6, 64)
+		m.targetLocation.WithLabelValues(host, lat, lon).Set(1)
+	}
+}
+
+func (m *metrics) resolveError(source, host string) {
+	m.resolveErrors.WithLabelValues(host, source).Inc()
+}
+
+// geoLabels builds the label set for a check, attaching info when the
+// caller already resolved it (a -geoip database is configured and the
+// lookup succeeded).
+func geoLabels(host, source, module string, info geoInfo, hasInfo bool) prometheus.Labels {
+	l := prometheus.Labels{
+		"host":      host,
+		"source":    source,
+		"module":    module,
+		"country":   "",
+		"continent": "",
+		"city":      "",
+	}
+	if hasInfo {
+		l["country"] = info.Country
+		l["continent"] = info.Continent
+		l["city"] = info.City
+	}
+	return l
+}
@@ -0,0 +1,43 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// withNetns runs fn with the calling goroutine's OS thread switched into
+// the network namespace at path, restoring the original namespace
+// afterward. Network namespaces are a per-thread property on Linux, so the
+// goroutine is locked to its thread for the duration.
+func withNetns(path string, fn func() error) error {
+	if path == "" {
+		return fn()
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := os.Open("/proc/self/ns/net")
+	if err != nil {
+		return fmt.Errorf("open current netns: %s", err)
+	}
+	defer orig.Close()
+
+	ns, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open netns %s: %s", path, err)
+	}
+	defer ns.Close()
+
+	if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+		return fmt.Errorf("enter netns %s: %s", path, err)
+	}
+	defer unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET)
+
+	return fn()
+}
@@ -0,0 +1,72 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestWithNetnsEntersNamespace(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("requires root to create a network namespace")
+	}
+
+	nsPath, cleanup, err := newIsolatedNetns()
+	if err != nil {
+		t.Skipf("could not create an isolated netns: %s", err)
+	}
+	defer cleanup()
+
+	var ifaces []net.Interface
+	err = withNetns(nsPath, func() error {
+		var err error
+		ifaces, err = net.Interfaces()
+		return err
+	})
+	if err != nil {
+		t.Fatalf("withNetns: %s", err)
+	}
+
+	if len(ifaces) != 1 || ifaces[0].Name != "lo" {
+		t.Fatalf("expected only the loopback interface inside the isolated netns, got %v", ifaces)
+	}
+}
+
+// newIsolatedNetns creates a fresh network namespace on its own locked OS
+// thread (a brand new netns has nothing but "lo") and returns a /proc
+// path referring to it, plus a cleanup func that releases the thread.
+func newIsolatedNetns() (string, func(), error) {
+	done := make(chan error, 1)
+	ready := make(chan string, 1)
+	release := make(chan struct{})
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			ready <- ""
+			done <- err
+			return
+		}
+		ready <- fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid())
+		<-release
+		done <- nil
+	}()
+
+	path := <-ready
+	if path == "" {
+		return "", nil, <-done
+	}
+	cleanup := func() {
+		close(release)
+		<-done
+	}
+	return path, cleanup, nil
+}
@@ -0,0 +1,15 @@
+// +build !linux
+
+package main
+
+import "fmt"
+
+// withNetns runs fn directly on non-Linux platforms when path is empty,
+// and returns an error without running fn when a namespace path is given,
+// since network namespaces are a Linux-only concept.
+func withNetns(path string, fn func() error) error {
+	if path != "" {
+		return fmt.Errorf("network namespaces are only supported on linux")
+	}
+	return fn()
+}
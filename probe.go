@@ -0,0 +1,40 @@
+package main
+
+import "time"
+
+// probeOpts carries the vantage-point settings a Prober needs to reach a
+// target the way its prober is configured to: a source address, network
+// namespace, and routing mark.
+type probeOpts struct {
+	LocalAddr string
+	Netns     string
+	Mark      int
+	Source    string
+}
+
+// Prober is a pluggable check module. Each module owns its own notion of
+// concurrency and failure; probe invokes record once per target it was
+// given, with the outcome of that target's check.
+type Prober interface {
+	probe(targets []Target, timeout time.Duration, opts probeOpts, record func(t Target, ok bool, rtt time.Duration)) error
+}
+
+// proberModules maps a target's module name to the Prober that handles
+// it. "icmp" is handled separately by icmpProbe, since it predates this
+// interface and already owns resolve-error accounting.
+var proberModules = map[string]Prober{
+	"tcp":  tcpProber{},
+	"http": httpProber{},
+	"dns":  dnsProber{},
+}
+
+// groupByModule splits targets by their module, defaulting blank modules
+// to "icmp".
+func groupByModule(targets []Target) map[string][]Target {
+	groups := make(map[string][]Target)
+	for _, t := range targets {
+		m := t.module()
+		groups[m] = append(groups[m], t)
+	}
+	return groups
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// prober is a single vantage point: a source address (and optionally a
+// network namespace and routing mark) from which targets are probed.
+// Each prober reports its results under its own "source" metric label.
+type prober struct {
+	name  string
+	bind  string
+	netns string
+	mark  int
+}
+
+func newProber(c ProberConfig) *prober {
+	if c.Mark != 0 {
+		// Logged once here, at config load, rather than every ping()
+		// round: fastping owns its own socket, so a mark configured on
+		// this prober is simply ignored for any of its icmp targets.
+		log.Printf("prober %s: socket marks are not supported for the icmp module, ignoring mark %d for any icmp targets", c.Name, c.Mark)
+	}
+	return &prober{
+		name:  c.Name,
+		bind:  c.Bind,
+		netns: c.Netns,
+		mark:  c.Mark,
+	}
+}
+
+// ping probes targets from this prober's source address, dispatching
+// each target to the Prober module named by its Module field. Namespace
+// and mark handling live inside each module, close to the socket calls
+// they need to apply to, rather than here: probes run concurrently across
+// worker goroutines, and entering a namespace only affects the calling
+// goroutine's OS thread.
+func (pr *prober) ping(targets []Target) error {
+	start := time.Now()
+	defer func() {
+		pingMetrics.scrapeDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	opts := probeOpts{LocalAddr: pr.bind, Netns: pr.netns, Mark: pr.mark, Source: pr.name}
+
+	for module, group := range groupByModule(targets) {
+		if module == "icmp" {
+			if err := icmpProbe(pr.name, group, *pingTimeout, pr.bind, pr.netns); err != nil {
+				return err
+			}
+			continue
+		}
+		mod, ok := proberModules[module]
+		if !ok {
+			log.Printf("prober %s: unknown module %q", pr.name, module)
+			continue
+		}
+		record := func(t Target, ok bool, rtt time.Duration) {
+			pingMetrics.update(pr.name, t.Host, module, ok, rtt)
+		}
+		if err := mod.probe(group, *pingTimeout, opts, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
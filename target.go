@@ -0,0 +1,28 @@
+package main
+
+// Target is a single thing to probe: a host plus the module that should
+// check it and that module's parameters. Module defaults to "icmp" when
+// left blank, to stay compatible with plain host entries.
+type Target struct {
+	Host   string `yaml:"host"`
+	Module string `yaml:"module,omitempty"`
+
+	// tcp
+	Port int `yaml:"port,omitempty"`
+
+	// http
+	URL   string `yaml:"url,omitempty"`
+	Regex string `yaml:"regex,omitempty"`
+
+	// dns
+	Nameserver string `yaml:"nameserver,omitempty"`
+	Record     string `yaml:"record,omitempty"`
+	Answer     string `yaml:"answer,omitempty"`
+}
+
+func (t Target) module() string {
+	if t.Module == "" {
+		return "icmp"
+	}
+	return t.Module
+}
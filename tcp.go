@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tcpProber measures TCP connect time to host:port.
+type tcpProber struct{}
+
+func (tcpProber) probe(targets []Target, timeout time.Duration, opts probeOpts, record func(t Target, ok bool, rtt time.Duration)) error {
+	var dialer net.Dialer
+	dialer.Timeout = timeout
+	dialer.Control = controlMark(opts.Mark)
+	if opts.LocalAddr != "" {
+		la, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(opts.LocalAddr, "0"))
+		if err != nil {
+			return err
+		}
+		dialer.LocalAddr = la
+	}
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		t := t
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			var conn net.Conn
+			err := withNetns(opts.Netns, func() error {
+				addr := net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+				c, err := dialer.Dial("tcp", addr)
+				conn = c
+				return err
+			})
+			if err != nil {
+				record(t, false, 0)
+				return
+			}
+			conn.Close()
+			record(t, true, time.Since(start))
+		}()
+	}
+	wg.Wait()
+	return nil
+}